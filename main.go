@@ -3,8 +3,12 @@ package main
 import (
 	"context"
 	"fmt"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/client"
 	"github.com/sirupsen/logrus"
@@ -13,12 +17,35 @@ import (
 
 func main() {
 	filterF := pflag.StringSliceP("filter", "f", []string{}, "Provide filter values (e.g. 'label=<key>=<value>')")
-	intervalF := pflag.Duration("interval", 24*time.Hour, "Cleaning job interval")
+	intervalF := pflag.Duration("interval", 24*time.Hour, "Cleaning job interval, used when --schedule is not set")
+	scheduleF := pflag.String("schedule", "", "Cron expression (standard 5-field, optional leading seconds field, or @daily/@weekly shortcuts); overrides --interval")
+	onceF := pflag.Bool("once", false, "Run a single prune cycle and exit, ignoring --interval/--schedule")
 	allF := pflag.BoolP("all", "a", false, "Remove all unused images not just dangling ones")
+	buildCacheF := pflag.Bool("build-cache", false, "Also prune the build cache")
+	keepStorageF := pflag.Int64("keep-storage", 0, "Amount of disk space in bytes to keep when pruning the build cache")
+	maxAgeF := pflag.String("max-age", "", "Skip images and containers newer than this age when pruning (duration like '72h' or an RFC3339 timestamp)")
+	labelExcludeF := pflag.StringSlice("label-exclude", []string{}, "Keep images whose labels match key[=value] (repeatable)")
+	labelIncludeF := pflag.StringSlice("label-include", []string{}, "Only prune images whose labels match key[=value] (repeatable)")
+	metricsAddrF := pflag.String("metrics-addr", "", "Address to serve Prometheus metrics on (e.g. ':9187'), disabled when empty")
+	reportFormatF := pflag.String("report-format", "text", "Prune report output format: 'text' or 'json'")
+	webhookURLF := pflag.String("webhook-url", "", "POST the JSON prune report to this URL after each cycle")
+	webhookTimeoutF := pflag.Duration("webhook-timeout", 10*time.Second, "Timeout for the --webhook-url POST request")
 	pflag.Parse()
 
 	logger := logrus.New()
 
+	if *reportFormatF != "text" && *reportFormatF != "json" {
+		logger.Fatalf("Invalid --report-format %q: must be 'text' or 'json'", *reportFormatF)
+	}
+
+	if *maxAgeF != "" {
+		if _, err := time.ParseDuration(*maxAgeF); err != nil {
+			if _, err := time.Parse(time.RFC3339, *maxAgeF); err != nil {
+				logger.Fatalf("Failed to parse --max-age as a duration or RFC3339 timestamp: %q", *maxAgeF)
+			}
+		}
+	}
+
 	var (
 		args = filters.NewArgs()
 		err  error
@@ -34,94 +61,315 @@ func main() {
 	if err != nil {
 		logger.Fatalf("Failed to create Docker client: %v", err)
 	}
+	defer cli.Close()
 
-	ticker := time.NewTicker(*intervalF)
-	defer func() {
-		ticker.Stop()
-		cli.Close()
-	}()
+	if *metricsAddrF != "" {
+		go serveMetrics(*metricsAddrF, logger)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	queue := make(chan struct{}, 1)
-	queue <- struct{}{}
+	opts := pruneOptions{
+		all:            *allF,
+		filter:         args,
+		buildCache:     *buildCacheF,
+		keepStorage:    *keepStorageF,
+		maxAge:         *maxAgeF,
+		labelInclude:   *labelIncludeF,
+		labelExclude:   *labelExcludeF,
+		reportFormat:   *reportFormatF,
+		webhookURL:     *webhookURLF,
+		webhookTimeout: *webhookTimeoutF,
+	}
+
+	if *onceF {
+		runOnce(ctx, logger, cli, opts)
+		return
+	}
+
+	sched, err := newSchedule(*scheduleF, *intervalF)
+	if err != nil {
+		logger.Fatalf("Failed to parse --schedule: %v", err)
+	}
+	runLoop(ctx, logger, cli, sched, opts)
+}
+
+// pruneOptions bundles the flags that describe a single prune cycle so that
+// runLoop/runOnce/runPrune don't have to keep passing them around
+// individually.
+type pruneOptions struct {
+	all            bool
+	filter         filters.Args
+	buildCache     bool
+	keepStorage    int64
+	maxAge         string
+	labelInclude   []string
+	labelExclude   []string
+	reportFormat   string
+	webhookURL     string
+	webhookTimeout time.Duration
+}
 
-	for {
+// runLoop drives prune cycles off sched until ctx is cancelled (SIGINT/
+// SIGTERM). When sched is interval-based it fires an initial cycle
+// immediately, matching the old --interval-only behavior; a cron schedule
+// instead waits for its first match, like any other cron job.
+func runLoop(ctx context.Context, logger *logrus.Logger, cli client.APIClient, sched schedule, opts pruneOptions) {
+	if _, ok := sched.(intervalSchedule); ok {
+		runOnce(ctx, logger, cli, opts)
+	}
+	for ctx.Err() == nil {
+		now := time.Now()
+		wait := sched.Next(now).Sub(now)
+		timer := time.NewTimer(wait)
 		select {
-		case <-ticker.C:
-			queue <- struct{}{}
-		case <-queue:
-			logger.Info("Start cleaning up unused data")
-			ctx, cancel := context.WithTimeout(context.Background(), *intervalF-time.Second)
-			defer cancel()
-			errCh := make(chan error)
-			go func() {
-				errCh <- runPrune(ctx, logger, cli, *allF, args)
-			}()
-			select {
-			case <-ctx.Done():
-				logger.Warn(ctx.Err().Error())
-			case err := <-errCh:
-				if err != nil {
-					logger.Error("Error occur: %v", err)
-				} else {
-					logger.Info("Finished cleaning")
-				}
+		case <-ctx.Done():
+			timer.Stop()
+			logger.Info("Shutting down")
+			return
+		case <-timer.C:
+		}
+		runOnce(ctx, logger, cli, opts)
+	}
+}
+
+// runOnce runs a single prune cycle, cancelling it if ctx is done before it
+// finishes (e.g. a signal during --once, or the parent context's deadline).
+func runOnce(ctx context.Context, logger *logrus.Logger, cli client.APIClient, opts pruneOptions) {
+	logger.Info("Start cleaning up unused data")
+	type outcome struct {
+		report PruneReport
+		err    error
+	}
+	outCh := make(chan outcome, 1)
+	go func() {
+		report, err := runPrune(ctx, logger, cli, opts)
+		outCh <- outcome{report, err}
+	}()
+	select {
+	case <-ctx.Done():
+		logger.Warn(ctx.Err().Error())
+	case out := <-outCh:
+		emitReport(logger, opts.reportFormat, out.report)
+		if opts.webhookURL != "" {
+			if err := postWebhook(ctx, out.report, opts.webhookURL, opts.webhookTimeout); err != nil {
+				logger.Errorf("Failed to post prune report to webhook: %v", err)
 			}
 		}
+		if out.err != nil {
+			logger.Errorf("Error occur: %v", out.err)
+		} else {
+			logger.Info("Finished cleaning")
+		}
 	}
 }
 
-func runPrune(ctx context.Context, logger *logrus.Logger, cli client.APIClient, all bool, pruneFilter filters.Args) error {
-	pruneFuncs := []func(context.Context, client.APIClient, bool, filters.Args) (uint64, string, error){
-		pruneContainers,
-		pruneImages,
+// runPrune runs every pruneFuncs entry in turn and assembles the results
+// into a PruneReport. A resource that errors is recorded in its
+// ResourceReport rather than aborting the remaining steps, so one failing
+// step (e.g. volumes) doesn't hide whether the others succeeded; runPrune
+// still returns the first error so callers can tell the cycle wasn't clean.
+func runPrune(ctx context.Context, logger *logrus.Logger, cli client.APIClient, opts pruneOptions) (PruneReport, error) {
+	report := PruneReport{StartedAt: time.Now()}
+	pruneFuncs := []func(context.Context, client.APIClient, bool, filters.Args) pruneResult{
+		func(ctx context.Context, cli client.APIClient, all bool, pruneFilter filters.Args) pruneResult {
+			return pruneContainers(ctx, cli, all, pruneFilter, opts.maxAge)
+		},
+		func(ctx context.Context, cli client.APIClient, all bool, pruneFilter filters.Args) pruneResult {
+			return pruneImages(ctx, cli, all, pruneFilter, opts.maxAge, opts.labelInclude, opts.labelExclude)
+		},
 		pruneNetworks,
 		pruneVolumes,
 	}
-	var total uint64
+	if opts.buildCache {
+		pruneFuncs = append(pruneFuncs, func(ctx context.Context, cli client.APIClient, all bool, pruneFilter filters.Args) pruneResult {
+			return pruneBuildCache(ctx, cli, all, pruneFilter, opts.keepStorage)
+		})
+	}
+	var firstErr error
 	for i := range pruneFuncs {
-		spaceReclaimed, output, err := pruneFuncs[i](ctx, cli, all, pruneFilter)
-		if err != nil {
-			return err
+		result := pruneFuncs[i](ctx, cli, opts.all, opts.filter)
+		recordMetrics(result)
+		resourceReport := ResourceReport{
+			Resource:            result.Resource,
+			Deleted:             result.Deleted,
+			SpaceReclaimed:      result.SpaceReclaimed,
+			SpaceReclaimedHuman: humanSize(result.SpaceReclaimed),
+		}
+		if result.Err != nil {
+			resourceReport.Error = result.Err.Error()
+			if firstErr == nil {
+				firstErr = result.Err
+			}
+		} else {
+			report.TotalReclaimed += result.SpaceReclaimed
 		}
-		total += spaceReclaimed
-		logger.Info(output)
+		report.Resources = append(report.Resources, resourceReport)
+	}
+	report.FinishedAt = time.Now()
+	report.TotalReclaimedHuman = humanSize(report.TotalReclaimed)
+	if firstErr == nil {
+		lastRunTimestamp.SetToCurrentTime()
+		runDuration.Set(report.FinishedAt.Sub(report.StartedAt).Seconds())
 	}
-	logger.Infof("Total reclaimed space: %d", total)
-	return nil
+	return report, firstErr
 }
 
-func pruneContainers(ctx context.Context, cli client.APIClient, all bool, pruneFilter filters.Args) (uint64, string, error) {
-	report, err := cli.ContainersPrune(ctx, pruneFilter)
+func pruneContainers(ctx context.Context, cli client.APIClient, all bool, pruneFilter filters.Args, maxAge string) pruneResult {
+	newArgs := pruneFilter
+	if maxAge != "" {
+		newArgs = cloneArgs(pruneFilter)
+		newArgs.Add("until", maxAge)
+	}
+	report, err := cli.ContainersPrune(ctx, newArgs)
 	if err != nil {
-		return 0, "", err
+		return pruneResult{Resource: "containers", Err: err}
 	}
-	return report.SpaceReclaimed, fmt.Sprintf("Deleted Containers: %d, Reclaimed Space: %d", len(report.ContainersDeleted), report.SpaceReclaimed), nil
+	return pruneResult{Resource: "containers", Deleted: len(report.ContainersDeleted), SpaceReclaimed: report.SpaceReclaimed}
 }
 
-func pruneNetworks(ctx context.Context, cli client.APIClient, all bool, pruneFilter filters.Args) (uint64, string, error) {
+// pruneNetworks does not accept --max-age: the daemon does not honor an
+// "until" filter for network prune requests.
+func pruneNetworks(ctx context.Context, cli client.APIClient, all bool, pruneFilter filters.Args) pruneResult {
 	report, err := cli.NetworksPrune(ctx, pruneFilter)
 	if err != nil {
-		return 0, "", err
+		return pruneResult{Resource: "networks", Err: err}
 	}
-	return 0, fmt.Sprintf("Deleted Networks: %d", len(report.NetworksDeleted)), nil
+	return pruneResult{Resource: "networks", Deleted: len(report.NetworksDeleted)}
 }
 
-func pruneVolumes(ctx context.Context, cli client.APIClient, all bool, pruneFilter filters.Args) (uint64, string, error) {
+// pruneVolumes does not accept --max-age, for the same reason as
+// pruneNetworks: the daemon has no "until" filter for volume prune.
+func pruneVolumes(ctx context.Context, cli client.APIClient, all bool, pruneFilter filters.Args) pruneResult {
 	report, err := cli.VolumesPrune(ctx, pruneFilter)
 	if err != nil {
-		return 0, "", err
+		return pruneResult{Resource: "volumes", Err: err}
 	}
-	return report.SpaceReclaimed, fmt.Sprintf("Deleted Volumes: %d, Reclaimed Space: %d", len(report.VolumesDeleted), report.SpaceReclaimed), nil
+	return pruneResult{Resource: "volumes", Deleted: len(report.VolumesDeleted), SpaceReclaimed: report.SpaceReclaimed}
 }
 
-func pruneImages(ctx context.Context, cli client.APIClient, all bool, pruneFilter filters.Args) (uint64, string, error) {
+func pruneImages(ctx context.Context, cli client.APIClient, all bool, pruneFilter filters.Args, maxAge string, labelInclude, labelExclude []string) pruneResult {
 	newArgs := cloneArgs(pruneFilter)
 	newArgs.Add("dangling", fmt.Sprintf("%v", !all))
+	if maxAge != "" {
+		newArgs.Add("until", maxAge)
+	}
+	if len(labelInclude) > 0 || len(labelExclude) > 0 {
+		return pruneImagesByLabel(ctx, cli, newArgs, labelInclude, labelExclude)
+	}
 	report, err := cli.ImagesPrune(ctx, newArgs)
 	if err != nil {
-		return 0, "", err
+		return pruneResult{Resource: "images", Err: err}
+	}
+	return pruneResult{Resource: "images", Deleted: len(report.ImagesDeleted), SpaceReclaimed: report.SpaceReclaimed}
+}
+
+// pruneImagesByLabel handles --label-include/--label-exclude, which the
+// daemon's own "label=" prune filter cannot express (it only matches images
+// carrying a given label, never its absence, and has no wildcard support).
+// It lists the candidates the daemon would otherwise prune itself, filters
+// them in-process against the label predicates, and removes the survivors
+// one at a time. Label values are compared exactly; wildcard patterns in a
+// key=value expression are not expanded.
+func pruneImagesByLabel(ctx context.Context, cli client.APIClient, pruneFilter filters.Args, labelInclude, labelExclude []string) pruneResult {
+	// All is always false here: it controls whether ImageList includes
+	// intermediate build-layer images (ones with dependent children), not
+	// whether non-dangling/tagged images are candidates - that's already
+	// handled by the "dangling" filter entry pruneImages adds. ImagesPrune
+	// itself never touches images with dependent children, so neither should
+	// this path.
+	images, err := cli.ImageList(ctx, types.ImageListOptions{All: false, Filters: pruneFilter})
+	if err != nil {
+		return pruneResult{Resource: "images", Err: err}
+	}
+
+	includes := newLabelPredicates(labelInclude)
+	excludes := newLabelPredicates(labelExclude)
+
+	var (
+		deleted        int
+		spaceReclaimed uint64
+		firstErr       error
+	)
+	for _, image := range images {
+		if !matchesAll(includes, image.Labels) || matchesAny(excludes, image.Labels) {
+			continue
+		}
+		// Force is deliberately false, not tied to --all: force-removing a
+		// still-tagged or in-use image is not something ImagesPrune itself
+		// would ever do. Mirror its safer default instead of forcing here.
+		items, err := cli.ImageRemove(ctx, image.ID, types.ImageRemoveOptions{Force: false})
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		for _, item := range items {
+			if item.Deleted == image.ID {
+				deleted++
+				spaceReclaimed += uint64(image.Size)
+				break
+			}
+		}
+	}
+	return pruneResult{Resource: "images", Deleted: deleted, SpaceReclaimed: spaceReclaimed, Err: firstErr}
+}
+
+// labelPredicate reports whether an image's labels satisfy a single
+// key[=value] filter expression.
+type labelPredicate func(labels map[string]string) bool
+
+func newLabelPredicates(exprs []string) []labelPredicate {
+	predicates := make([]labelPredicate, len(exprs))
+	for i, expr := range exprs {
+		key, value, hasValue := expr, "", false
+		if idx := strings.IndexByte(expr, '='); idx >= 0 {
+			key, value, hasValue = expr[:idx], expr[idx+1:], true
+		}
+		predicates[i] = func(labels map[string]string) bool {
+			v, ok := labels[key]
+			if !ok {
+				return false
+			}
+			if !hasValue {
+				return true
+			}
+			return v == value
+		}
+	}
+	return predicates
+}
+
+func matchesAll(predicates []labelPredicate, labels map[string]string) bool {
+	for _, p := range predicates {
+		if !p(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesAny(predicates []labelPredicate, labels map[string]string) bool {
+	for _, p := range predicates {
+		if p(labels) {
+			return true
+		}
+	}
+	return false
+}
+
+func pruneBuildCache(ctx context.Context, cli client.APIClient, all bool, pruneFilter filters.Args, keepStorage int64) pruneResult {
+	report, err := cli.BuildCachePrune(ctx, types.BuildCachePruneOptions{
+		All:         all,
+		Filters:     pruneFilter,
+		KeepStorage: keepStorage,
+	})
+	if err != nil {
+		return pruneResult{Resource: "build_cache", Err: err}
 	}
-	return report.SpaceReclaimed, fmt.Sprintf("Deleted Images: %d, Reclaimed Space: %d", len(report.ImagesDeleted), report.SpaceReclaimed), nil
+	return pruneResult{Resource: "build_cache", Deleted: len(report.CachesDeleted), SpaceReclaimed: report.SpaceReclaimed}
 }
 
 // In older versions of docker/client, filters.Args struct does not implement Clone() function
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+// pruneResult is what each pruneFuncs entry returns: enough to log a
+// human-readable line and feed the Prometheus collectors below, without
+// either side having to reparse a preformatted string.
+type pruneResult struct {
+	Resource       string
+	Deleted        int
+	SpaceReclaimed uint64
+	Err            error
+}
+
+var (
+	lastRunTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "dockerprune_last_run_timestamp_seconds",
+		Help: "Unix timestamp of the last completed prune run.",
+	})
+	runDuration = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "dockerprune_run_duration_seconds",
+		Help: "Duration of the last prune run, in seconds.",
+	})
+	spaceReclaimedBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dockerprune_space_reclaimed_bytes",
+		Help: "Space reclaimed by the last prune run, per resource.",
+	}, []string{"resource"})
+	deletedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "dockerprune_deleted_total",
+		Help: "Total number of resources deleted by prune runs.",
+	}, []string{"resource"})
+	errorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "dockerprune_errors_total",
+		Help: "Total number of errors encountered by prune runs, per resource.",
+	}, []string{"resource"})
+)
+
+func init() {
+	prometheus.MustRegister(lastRunTimestamp, runDuration, spaceReclaimedBytes, deletedTotal, errorsTotal)
+}
+
+// recordMetrics feeds a single resource's prune result into the collectors
+// above. It does not touch lastRunTimestamp/runDuration, which describe the
+// whole run rather than one step.
+func recordMetrics(result pruneResult) {
+	if result.Err != nil {
+		errorsTotal.WithLabelValues(result.Resource).Inc()
+		return
+	}
+	spaceReclaimedBytes.WithLabelValues(result.Resource).Set(float64(result.SpaceReclaimed))
+	deletedTotal.WithLabelValues(result.Resource).Add(float64(result.Deleted))
+}
+
+// serveMetrics serves the Prometheus metrics endpoint on addr and blocks, so
+// main runs it in its own goroutine. It never returns except on listener
+// failure, which is logged rather than fatal since it shouldn't take the
+// prune loop down with it.
+func serveMetrics(addr string, logger *logrus.Logger) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	logger.Infof("Serving metrics on %s/metrics", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		logger.Errorf("Metrics server stopped: %v", err)
+	}
+}
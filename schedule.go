@@ -0,0 +1,36 @@
+package main
+
+import (
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// schedule reports when the next prune cycle is due, given the previous
+// one. cron.Schedule already has exactly this shape, so a cron expression
+// and a fixed interval can share one abstraction.
+type schedule interface {
+	Next(time.Time) time.Time
+}
+
+// intervalSchedule implements schedule on top of a fixed time.Duration,
+// preserving the original --interval behavior.
+type intervalSchedule time.Duration
+
+func (d intervalSchedule) Next(prev time.Time) time.Time {
+	return prev.Add(time.Duration(d))
+}
+
+var scheduleParser = cron.NewParser(
+	cron.Second | cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
+// newSchedule builds a schedule from --schedule if set (standard 5-field
+// cron, an optional leading seconds field, or a @daily/@weekly-style
+// descriptor), falling back to the --interval duration otherwise.
+func newSchedule(expr string, interval time.Duration) (schedule, error) {
+	if expr == "" {
+		return intervalSchedule(interval), nil
+	}
+	return scheduleParser.Parse(expr)
+}